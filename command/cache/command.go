@@ -0,0 +1,160 @@
+// Package cache implements the `packer cache` command, which inspects
+// and garbage-collects the local cache staging area used by the
+// packer/cache backends (list/prune/verify).
+package cache
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/packer/packer"
+	cachepkg "github.com/mitchellh/packer/packer/cache"
+)
+
+// Command is the `packer cache` implementation. Like the rest of
+// packer's built-in commands, it's selected by name through
+// config.LoadCommand.
+type Command struct{}
+
+func (c Command) Run(env packer.Environment, args []string) int {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		env.Ui().Error("Usage: packer cache [list|prune|verify]")
+		return 1
+	}
+
+	ca, ok := env.Cache().(cachepkg.Cache)
+	if !ok {
+		env.Ui().Error("The configured cache backend doesn't support the `packer cache` command.")
+		return 1
+	}
+
+	switch sub := rest[0]; sub {
+	case "list":
+		return c.list(env, ca)
+	case "prune":
+		return c.prune(env, ca)
+	case "verify":
+		return c.verify(env, ca)
+	default:
+		env.Ui().Error(fmt.Sprintf("Unknown cache subcommand: %s", sub))
+		return 1
+	}
+}
+
+func (c Command) list(env packer.Environment, ca cachepkg.Cache) int {
+	keys, err := ca.Keys()
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Error listing cache entries: %s", err))
+		return 1
+	}
+
+	for _, key := range keys {
+		line := key
+		if m, err := ca.Metadata(key); err == nil && m != nil {
+			line = fmt.Sprintf("%s\t%d bytes\tlast used %s", key, m.Size, m.LastAccess.Format("2006-01-02"))
+		}
+		env.Ui().Say(line)
+	}
+
+	return 0
+}
+
+// prune removes entries whose metadata marks them older than
+// staleAfter without being accessed. Entries with no metadata
+// (written before this subsystem existed) are left alone.
+func (c Command) prune(env packer.Environment, ca cachepkg.Cache) int {
+	keys, err := ca.Keys()
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Error listing cache entries: %s", err))
+		return 1
+	}
+
+	removed := 0
+	for _, key := range keys {
+		m, err := ca.Metadata(key)
+		if err != nil || m == nil {
+			continue
+		}
+
+		if isStale(m) {
+			if err := ca.Remove(key); err != nil {
+				env.Ui().Error(fmt.Sprintf("Error removing %s: %s", key, err))
+				continue
+			}
+			removed++
+		}
+	}
+
+	env.Ui().Say(fmt.Sprintf("Removed %d stale cache entries.", removed))
+	return 0
+}
+
+func (c Command) verify(env packer.Environment, ca cachepkg.Cache) int {
+	keys, err := ca.Keys()
+	if err != nil {
+		env.Ui().Error(fmt.Sprintf("Error listing cache entries: %s", err))
+		return 1
+	}
+
+	bad := 0
+	for _, key := range keys {
+		m, err := ca.Metadata(key)
+		if err != nil {
+			env.Ui().Error(fmt.Sprintf("%s: error reading metadata: %s", key, err))
+			bad++
+			continue
+		}
+		if m == nil {
+			env.Ui().Say(fmt.Sprintf("%s: no metadata, skipped", key))
+			continue
+		}
+
+		path := ca.RLock(key)
+		sum, err := checksumFile(path)
+		ca.Unlock(key)
+		if err != nil {
+			env.Ui().Error(fmt.Sprintf("%s: error checksumming: %s", key, err))
+			bad++
+			continue
+		}
+
+		if !strings.EqualFold(sum, m.Checksum) {
+			env.Ui().Error(fmt.Sprintf("%s: checksum mismatch (expected %s, got %s)", key, m.Checksum, sum))
+			bad++
+			continue
+		}
+
+		env.Ui().Say(fmt.Sprintf("%s: OK", key))
+	}
+
+	if bad > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (c Command) Help() string {
+	return `Usage: packer cache [list|prune|verify]
+
+  Inspects and garbage-collects the local packer cache.
+
+Subcommands:
+
+  list    Lists every entry currently in the cache, with size and
+          last-access time when known.
+  prune   Removes entries that haven't been accessed recently.
+  verify  Recomputes the checksum of every entry with recorded
+          metadata and reports any that don't match.
+`
+}
+
+func (c Command) Synopsis() string {
+	return "manage the local build cache"
+}
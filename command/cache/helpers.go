@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"time"
+
+	cachepkg "github.com/mitchellh/packer/packer/cache"
+)
+
+// staleAfter is how long a cache entry can go unused before `packer
+// cache prune` considers it a candidate for removal.
+const staleAfter = 30 * 24 * time.Hour
+
+func isStale(m *cachepkg.Metadata) bool {
+	return time.Since(m.LastAccess) > staleAfter
+}
+
+func checksumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
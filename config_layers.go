@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// includeDir is where layered config files are discovered, in
+// addition to the traditional single config file. Files are merged
+// in lexical order, so "10-plugins.json" is applied before
+// "20-cache.json".
+const includeDir = "~/.packer.d/conf.d"
+
+// refPattern matches ${env:VAR} and ${file:path} references inside
+// string values. It's applied to raw JSON bytes before decoding so it
+// works uniformly across every string field without needing to walk
+// the decoded config structure.
+var refPattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// expandRefs replaces ${env:VAR} with the value of the VAR
+// environment variable and ${file:path} with the trimmed contents of
+// path, operating directly on the raw JSON text.
+func expandRefs(data []byte) ([]byte, error) {
+	var expandErr error
+
+	expanded := refPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := refPattern.FindSubmatch(match)
+		kind, arg := string(groups[1]), string(groups[2])
+
+		switch kind {
+		case "env":
+			return []byte(jsonEscape(os.Getenv(arg)))
+
+		case "file":
+			contents, err := ioutil.ReadFile(arg)
+			if err != nil {
+				expandErr = fmt.Errorf("error expanding ${file:%s}: %s", arg, err)
+				return match
+			}
+			return []byte(jsonEscape(strings.TrimSpace(string(contents))))
+		}
+
+		return match
+	})
+
+	if expandErr != nil {
+		return nil, expandErr
+	}
+
+	return expanded, nil
+}
+
+// jsonEscape escapes s so it can be substituted directly into a JSON
+// string literal that's already being built as raw text.
+func jsonEscape(s string) string {
+	encoded, _ := json.Marshal(s)
+	// json.Marshal wraps the string in quotes; strip them since the
+	// surrounding quotes in the source document are kept as-is.
+	return string(encoded[1 : len(encoded)-1])
+}
+
+// includeFiles returns the *.json files under includeDir, in lexical
+// order. A missing directory is not an error: include files are
+// entirely optional.
+func includeFiles() ([]string, error) {
+	dir := includeDir
+	if len(dir) >= 2 && dir[:2] == "~/" {
+		home, err := homeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// mergeConfigDocs merges a sequence of JSON object documents,
+// with later documents overriding earlier ones key by key. Nested
+// objects (builders, commands, hooks, post-processors, provisioners,
+// cache) are merged recursively so that, for example, a conf.d file
+// can override a single builder path without having to repeat every
+// other builder override from the base config.
+func mergeConfigDocs(docs [][]byte) ([]byte, error) {
+	merged := map[string]interface{}{}
+
+	for _, doc := range docs {
+		var m map[string]interface{}
+		if err := json.Unmarshal(doc, &m); err != nil {
+			return nil, err
+		}
+
+		mergeInto(merged, m)
+	}
+
+	return json.Marshal(merged)
+}
+
+func mergeInto(dst, src map[string]interface{}) {
+	for key, srcVal := range src {
+		if dstVal, ok := dst[key]; ok {
+			dstMap, dstIsMap := dstVal.(map[string]interface{})
+			srcMap, srcIsMap := srcVal.(map[string]interface{})
+			if dstIsMap && srcIsMap {
+				mergeInto(dstMap, srcMap)
+				continue
+			}
+		}
+
+		dst[key] = srcVal
+	}
+}
+
+// homeDir is a small indirection so config file discovery can be
+// exercised without depending on the real user's home directory.
+var homeDir = func() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+	return "", fmt.Errorf("could not detect home directory")
+}
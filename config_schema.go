@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// configSchemaJSON is the embedded JSON Schema describing every key
+// the layered config loader understands: plugin path overrides,
+// command overrides, and cache settings. Keeping it embedded means a
+// typo'd key (or one put in the wrong place) is caught at load time
+// with a useful error instead of being silently ignored, which is
+// what happened before this file existed.
+const configSchemaJSON = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "plugin_min_port": {"type": "number"},
+    "plugin_max_port": {"type": "number"},
+    "disable_checkpoint": {"type": "boolean"},
+    "disable_checkpoint_signature": {"type": "boolean"},
+    "builders": {"type": "object", "additionalProperties": {"type": "string"}},
+    "commands": {"type": "object", "additionalProperties": {"type": "string"}},
+    "hooks": {"type": "object", "additionalProperties": {"type": "string"}},
+    "post-processors": {"type": "object", "additionalProperties": {"type": "string"}},
+    "provisioners": {"type": "object", "additionalProperties": {"type": "string"}},
+    "cache": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "backend": {"type": "string"},
+        "dir": {"type": "string"},
+        "s3_bucket": {"type": "string"},
+        "s3_prefix": {"type": "string"},
+        "http_url": {"type": "string"}
+      }
+    }
+  }
+}`
+
+// schemaNode is a (small) subset of JSON Schema: enough to describe
+// packer's config file without pulling in a full external validator.
+// It supports object/array/string/number/boolean/null types, nested
+// "properties", "additionalProperties" (as either a bool or a schema
+// that every extra property must match), and array "items".
+type schemaNode struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+}
+
+// SchemaError is returned when a config document fails validation. It
+// carries the JSON path of the offending value (e.g. "$.builders.foo")
+// so the user doesn't have to guess which part of a merged,
+// multi-file config is wrong.
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("config error at %s: %s", e.Path, e.Message)
+}
+
+func loadConfigSchema() (*schemaNode, error) {
+	var root schemaNode
+	if err := json.Unmarshal([]byte(configSchemaJSON), &root); err != nil {
+		return nil, fmt.Errorf("invalid embedded config schema: %s", err)
+	}
+	return &root, nil
+}
+
+// validateConfigDocument validates raw (a merged, already env/file
+// expanded config document) against the embedded schema.
+func validateConfigDocument(raw []byte) error {
+	schema, err := loadConfigSchema()
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("config is not valid JSON: %s", err)
+	}
+
+	return validateNode("$", schema, doc)
+}
+
+func validateNode(path string, schema *schemaNode, value interface{}) error {
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return &SchemaError{path, "expected an object"}
+		}
+
+		additionalAllowed, additionalSchema := parseAdditionalProperties(schema.AdditionalProperties)
+
+		for key, val := range obj {
+			childPath := path + "." + key
+
+			if child, known := schema.Properties[key]; known {
+				if err := validateNode(childPath, child, val); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !additionalAllowed {
+				return &SchemaError{childPath, "unknown configuration key"}
+			}
+
+			if err := validateNode(childPath, additionalSchema, val); err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return &SchemaError{path, "expected an array"}
+		}
+		for i, v := range arr {
+			if err := validateNode(fmt.Sprintf("%s[%d]", path, i), schema.Items, v); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		if _, ok := value.(string); !ok {
+			return &SchemaError{path, "expected a string"}
+		}
+
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return &SchemaError{path, "expected a number"}
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &SchemaError{path, "expected a boolean"}
+		}
+
+	case "null":
+		// A nil value already returned above.
+		return &SchemaError{path, "expected null"}
+	}
+
+	return nil
+}
+
+// parseAdditionalProperties interprets the raw "additionalProperties"
+// value: a bare `false` disallows unknown keys entirely, a schema
+// object constrains what unknown keys must look like, and anything
+// else (including absence) allows unknown keys with no constraint.
+func parseAdditionalProperties(raw json.RawMessage) (allowed bool, schema *schemaNode) {
+	if len(raw) == 0 {
+		return true, nil
+	}
+
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+
+	var s schemaNode
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return true, &s
+	}
+
+	return true, nil
+}
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/packer/packer"
+	"github.com/mitchellh/packer/packer/cache"
+	"github.com/mitchellh/packer/packer/logging"
 	"github.com/mitchellh/packer/packer/plugin"
 	"io"
 	"io/ioutil"
@@ -15,10 +17,14 @@ import (
 )
 
 func main() {
-	// Setup logging if PACKER_LOG is set.
-	// Log to PACKER_LOG_PATH if it is set, otherwise default to stderr.
+	// Setup logging if PACKER_LOG is set. PACKER_LOG may be a bare "1"
+	// (legacy behavior, treated as DEBUG) or a level name such as
+	// DEBUG/TRACE/INFO/WARN/ERROR. Log to PACKER_LOG_PATH if it is set,
+	// otherwise default to stderr. PACKER_LOG_FORMAT=json switches the
+	// output to one JSON object per line.
 	var logOutput io.Writer = ioutil.Discard
-	if os.Getenv("PACKER_LOG") != "" {
+	logEnv := os.Getenv("PACKER_LOG")
+	if logEnv != "" {
 		logOutput = os.Stderr
 
 		if logPath := os.Getenv("PACKER_LOG_PATH"); logPath != "" {
@@ -34,17 +40,34 @@ func main() {
 		}
 	}
 
-	log.SetOutput(logOutput)
+	logger := logging.New(
+		logOutput,
+		logging.ParseLevel(logEnv),
+		logging.ParseFormat(os.Getenv("PACKER_LOG_FORMAT")),
+		"core")
+
+	// Flush the logger from plugin.CleanupClients rather than a local
+	// defer: a deferred call never runs across the os.Exit calls below
+	// (os.Exit skips deferred functions entirely), so every exit path
+	// from here on, including the early ones during config/cache setup,
+	// calls plugin.CleanupClients() explicitly instead.
+	plugin.RegisterCleanupHook(func() { logger.Flush() })
+
+	// Route the stdlib `log` package through our leveled logger instead
+	// of writing straight to logOutput, so call sites that haven't been
+	// converted to logger.Debug/Info/... yet still get filtered,
+	// formatted, and funneled through the one buffered writer instead
+	// of racing it for the same stream.
+	log.SetOutput(logger)
 
 	// If there is no explicit number of Go threads to use, then set it
 	if os.Getenv("GOMAXPROCS") == "" {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
 
-	log.Printf(
-		"Packer Version: %s %s %s",
+	logger.Info("Packer Version: %s %s %s",
 		packer.Version, packer.VersionPrerelease, packer.GitCommit)
-	log.Printf("Packer Target OS/Arch: %s %s", runtime.GOOS, runtime.GOARCH)
+	logger.Info("Packer Target OS/Arch: %s %s", runtime.GOOS, runtime.GOARCH)
 
 	// Prepare stdin for plugin usage by switching it to a pipe
 	setupStdin()
@@ -52,10 +75,11 @@ func main() {
 	config, err := loadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: \n\n%s\n", err)
+		plugin.CleanupClients()
 		os.Exit(1)
 	}
 
-	log.Printf("Packer config: %+v", config)
+	logger.Debug("Packer config: %+v", config)
 
 	cacheDir := os.Getenv("PACKER_CACHE_DIR")
 	if cacheDir == "" {
@@ -65,26 +89,37 @@ func main() {
 	cacheDir, err = filepath.Abs(cacheDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error preparing cache directory: \n\n%s\n", err)
+		plugin.CleanupClients()
 		os.Exit(1)
 	}
 
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		fmt.Fprintf(os.Stderr, "Error preparing cache directory: \n\n%s\n", err)
+		plugin.CleanupClients()
 		os.Exit(1)
 	}
 
-	log.Printf("Setting cache directory: %s", cacheDir)
-	cache := &packer.FileCache{CacheDir: cacheDir}
+	cacheLogger := logger.WithComponent("cache")
+	cacheLogger.Debug("Setting cache directory: %s", cacheDir)
+	buildCache, err := cache.FromEnv(cacheDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error preparing cache: \n\n%s\n", err)
+		plugin.CleanupClients()
+		os.Exit(1)
+	}
 
 	// Determine if we're in machine-readable mode by mucking around with
 	// the arguments...
 	args, machineReadable := extractMachineReadable(os.Args[1:])
 
-	defer plugin.CleanupClients()
-
-	// Create the environment configuration
+	// Create the environment configuration. Components loaded as plugin
+	// subprocesses (builders, provisioners, ...) inherit PACKER_LOG and
+	// PACKER_LOG_FORMAT the same way they inherit every other
+	// environment variable, since config.Load* launches them without
+	// overriding the child process's environment; that's what keeps
+	// their log level and format in sync with the parent's.
 	envConfig := packer.DefaultEnvironmentConfig()
-	envConfig.Cache = cache
+	envConfig.Cache = buildCache
 	envConfig.Commands = config.CommandNames()
 	envConfig.Components.Builder = config.LoadBuilder
 	envConfig.Components.Command = config.LoadCommand
@@ -134,47 +169,112 @@ func extractMachineReadable(args []string) ([]string, bool) {
 	return args, false
 }
 
+// loadConfig builds the effective configuration from, in order: the
+// compiled-in defaults, the user's config file, and any layered
+// include files under ~/.packer.d/conf.d/*.json (merged in lexical
+// order, later files winning key by key). ${env:VAR} and ${file:path}
+// references inside string values are expanded before any of it is
+// decoded, and the merged user-supplied layers (everything except the
+// compiled-in defaults) are validated against the embedded config
+// schema so a typo'd key produces an error instead of being silently
+// ignored.
+//
+// PACKER_CONFIG, when set, is an override: it's read on its own, with
+// no conf.d layering, exactly like before this file supported
+// composition.
 func loadConfig() (*config, error) {
-	var config config
-	if err := decodeConfig(bytes.NewBufferString(defaultConfig), &config); err != nil {
-		return nil, err
+	if configFilePath := os.Getenv("PACKER_CONFIG"); configFilePath != "" {
+		log.Printf("Attempting to open config file: %s", configFilePath)
+		doc, err := readConfigDoc(configFilePath, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeMergedConfig(doc)
 	}
 
-	mustExist := true
-	configFilePath := os.Getenv("PACKER_CONFIG")
-	if configFilePath == "" {
-		var err error
-		configFilePath, err = configFile()
-		mustExist = false
+	var userDocs [][]byte
 
+	configFilePath, err := configFile()
+	if err != nil {
+		log.Printf("Error detecting default config file path: %s", err)
+	} else if configFilePath != "" {
+		log.Printf("Attempting to open config file: %s", configFilePath)
+		doc, err := readConfigDoc(configFilePath, false)
 		if err != nil {
-			log.Printf("Error detecting default config file path: %s", err)
+			return nil, err
 		}
+		userDocs = append(userDocs, doc...)
 	}
 
-	if configFilePath == "" {
-		return &config, nil
+	includes, err := includeFiles()
+	if err != nil {
+		return nil, fmt.Errorf("error finding config includes: %s", err)
 	}
 
-	log.Printf("Attempting to open config file: %s", configFilePath)
-	f, err := os.Open(configFilePath)
-	if err != nil {
-		if !os.IsNotExist(err) {
+	for _, path := range includes {
+		log.Printf("Merging config include: %s", path)
+		doc, err := readConfigDoc(path, true)
+		if err != nil {
 			return nil, err
 		}
+		userDocs = append(userDocs, doc...)
+	}
+
+	return decodeMergedConfig(userDocs)
+}
+
+// readConfigDoc reads and expands a single config file's raw JSON. If
+// mustExist is false, a missing file is not an error and yields no
+// document at all.
+func readConfigDoc(path string, mustExist bool) ([][]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			log.Println("File doesn't exist, but doesn't need to. Ignoring.")
+			return nil, nil
+		}
+		return nil, err
+	}
 
-		if mustExist {
+	expanded, err := expandRefs(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding references in %s: %s", path, err)
+	}
+
+	return [][]byte{expanded}, nil
+}
+
+// decodeMergedConfig validates the merged *user-supplied* layers
+// (userDocs) against the embedded schema, then merges them on top of
+// the compiled-in defaultConfig and decodes the result into a
+// *config. defaultConfig is intentionally excluded from validation:
+// the schema only describes the user-facing config file format, and
+// validating the merged document (defaults included) would mean any
+// key defaultConfig sets that isn't also spelled out in the schema
+// fails every invocation, even with no user config present at all.
+func decodeMergedConfig(userDocs [][]byte) (*config, error) {
+	if len(userDocs) > 0 {
+		mergedUser, err := mergeConfigDocs(userDocs)
+		if err != nil {
+			return nil, fmt.Errorf("error merging configuration: %s", err)
+		}
+
+		if err := validateConfigDocument(mergedUser); err != nil {
 			return nil, err
 		}
+	}
 
-		log.Println("File doesn't exist, but doesn't need to. Ignoring.")
-		return &config, nil
+	all := append([][]byte{[]byte(defaultConfig)}, userDocs...)
+	merged, err := mergeConfigDocs(all)
+	if err != nil {
+		return nil, fmt.Errorf("error merging configuration: %s", err)
 	}
-	defer f.Close()
 
-	if err := decodeConfig(f, &config); err != nil {
+	var result config
+	if err := decodeConfig(bytes.NewReader(merged), &result); err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	return &result, nil
 }
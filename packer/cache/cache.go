@@ -0,0 +1,105 @@
+// Package cache implements the pluggable cache backends used to store
+// artifacts (ISOs, OVAs, and other large downloads) shared across
+// builds and, when configured with a remote backend, across CI
+// runners. It replaces the single hardcoded filesystem cache with an
+// interface so callers can choose a backend via configuration or
+// environment variables.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+)
+
+// Cache represents a place to store and retrieve files across builder
+// runs, such as an .iso file or .ova.
+type Cache interface {
+	// Lock returns a path that is exclusively locked for writing the
+	// value identified by key. The caller must call Unlock when done.
+	Lock(key string) string
+
+	// RLock returns a path to read the cached value identified by
+	// key, blocking until any writer holding Lock on the same key has
+	// released it.
+	RLock(key string) string
+
+	// Unlock releases a lock acquired by Lock or RLock.
+	Unlock(key string)
+
+	// LockContent behaves like Lock, but keys the entry by a SHA256
+	// checksum rather than an arbitrary name, so two differently
+	// named downloads of the same content share one cache entry.
+	LockContent(sha256 string) string
+
+	// RLockContent is the read-side counterpart to LockContent.
+	RLockContent(sha256 string) string
+
+	// Metadata returns the sidecar metadata recorded for key, or nil
+	// if the key has no metadata (for example, pre-existing entries
+	// written before this subsystem existed).
+	Metadata(key string) (*Metadata, error)
+
+	// PutMetadata records sidecar metadata for key. It should be
+	// called after the caller has finished writing and unlocked the
+	// entry.
+	PutMetadata(key string, m *Metadata) error
+
+	// Keys returns every key currently present in the cache. It is
+	// used by "packer cache list/prune/verify".
+	Keys() ([]string, error)
+
+	// Remove deletes a cache entry along with its metadata sidecar.
+	Remove(key string) error
+}
+
+// Metadata is the sidecar information recorded alongside a cache
+// entry so that entries can be inspected, verified, and garbage
+// collected without re-downloading them.
+type Metadata struct {
+	// Checksum is the SHA256 checksum of the cached content, hex
+	// encoded.
+	Checksum string `json:"checksum"`
+
+	// Size is the size of the cached content, in bytes.
+	Size int64 `json:"size"`
+
+	// SourceURL is the URL the content was originally downloaded
+	// from, if known.
+	SourceURL string `json:"source_url,omitempty"`
+
+	// LastAccess is updated every time the entry is locked for
+	// reading or writing, so stale entries can be identified.
+	LastAccess time.Time `json:"last_access"`
+}
+
+// ChecksumReader computes the SHA256 checksum of r as it is read,
+// without buffering it in memory. Backends use this to populate
+// Metadata.Checksum.
+type ChecksumReader struct {
+	r    io.Reader
+	hash interface {
+		io.Writer
+		Sum(b []byte) []byte
+	}
+}
+
+// NewChecksumReader wraps r so that reads through it also update a
+// running SHA256 hash.
+func NewChecksumReader(r io.Reader) *ChecksumReader {
+	return &ChecksumReader{r: r, hash: sha256.New()}
+}
+
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+// Sum256 returns the hex-encoded SHA256 checksum accumulated so far.
+func (c *ChecksumReader) Sum256() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
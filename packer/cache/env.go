@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FromEnv constructs the Cache backend selected by PACKER_CACHE_BACKEND
+// (file, s3, or http; defaults to file), using dir as local cache/
+// staging space. It's the entry point packer.go uses so that the
+// backend is a deployment-time choice rather than a compile-time one.
+func FromEnv(dir string) (Cache, error) {
+	switch backend := os.Getenv("PACKER_CACHE_BACKEND"); backend {
+	case "", "file":
+		return NewFileCache(dir)
+
+	case "s3":
+		bucket := os.Getenv("PACKER_CACHE_S3_BUCKET")
+		if bucket == "" {
+			return nil, fmt.Errorf("PACKER_CACHE_BACKEND=s3 requires PACKER_CACHE_S3_BUCKET")
+		}
+
+		prefix := os.Getenv("PACKER_CACHE_S3_PREFIX")
+		return NewS3Cache(newDefaultS3Bucket(), bucket, prefix, filepath.Join(dir, "s3"))
+
+	case "http":
+		baseURL := os.Getenv("PACKER_CACHE_HTTP_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("PACKER_CACHE_BACKEND=http requires PACKER_CACHE_HTTP_URL")
+		}
+
+		return NewHTTPCache(baseURL, filepath.Join(dir, "http"))
+
+	default:
+		return nil, fmt.Errorf("unknown PACKER_CACHE_BACKEND: %q", backend)
+	}
+}
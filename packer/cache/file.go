@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileCache implements Cache by storing entries as files in a local
+// directory, keyed by a sanitized version of the cache key. This is
+// the original packer cache behavior, now expressed as one backend
+// among several.
+type FileCache struct {
+	CacheDir string
+
+	locksMu sync.Mutex
+	locks   map[string]*sync.RWMutex
+	// writers tracks, per key, whether the currently held lock is the
+	// write side (true) or the read side (false), so Unlock knows
+	// which of l.Unlock/l.RUnlock to call. A RWMutex has no way to ask
+	// which kind of lock is held, and a mismatched Unlock/RUnlock call
+	// is a runtime.fatal that recover() cannot catch, so this has to
+	// be tracked explicitly rather than guessed at unlock time.
+	writers map[string]bool
+
+	// metadataMu serializes reads and writes of metadata sidecar
+	// files, since touch (from Lock/RLock) and PutMetadata can race
+	// on the same file from concurrent readers.
+	metadataMu sync.Mutex
+}
+
+// NewFileCache creates a FileCache rooted at dir, creating the
+// directory if it doesn't already exist.
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileCache{CacheDir: dir}, nil
+}
+
+func (f *FileCache) lockFor(key string) *sync.RWMutex {
+	f.locksMu.Lock()
+	defer f.locksMu.Unlock()
+
+	if f.locks == nil {
+		f.locks = make(map[string]*sync.RWMutex)
+	}
+
+	l, ok := f.locks[key]
+	if !ok {
+		l = new(sync.RWMutex)
+		f.locks[key] = l
+	}
+
+	return l
+}
+
+func (f *FileCache) setWriter(key string, write bool) {
+	f.locksMu.Lock()
+	defer f.locksMu.Unlock()
+
+	if f.writers == nil {
+		f.writers = make(map[string]bool)
+	}
+	f.writers[key] = write
+}
+
+func (f *FileCache) isWriter(key string) bool {
+	f.locksMu.Lock()
+	defer f.locksMu.Unlock()
+
+	return f.writers[key]
+}
+
+func (f *FileCache) Lock(key string) string {
+	f.lockFor(key).Lock()
+	f.setWriter(key, true)
+	f.touch(key)
+	return f.path(key)
+}
+
+func (f *FileCache) RLock(key string) string {
+	f.lockFor(key).RLock()
+	f.setWriter(key, false)
+	f.touch(key)
+	return f.path(key)
+}
+
+func (f *FileCache) Unlock(key string) {
+	l := f.lockFor(key)
+
+	if f.isWriter(key) {
+		l.Unlock()
+	} else {
+		l.RUnlock()
+	}
+}
+
+// touch bumps Metadata.LastAccess for an existing entry. It's a no-op
+// for entries with no metadata yet (e.g. one being populated for the
+// first time by its own Lock/Unlock), since there's nothing to bump.
+func (f *FileCache) touch(key string) {
+	f.metadataMu.Lock()
+	defer f.metadataMu.Unlock()
+
+	m, err := f.metadataLocked(key)
+	if err != nil || m == nil {
+		return
+	}
+
+	m.LastAccess = time.Now().UTC()
+	f.writeMetadataLocked(key, m)
+}
+
+func (f *FileCache) LockContent(sum string) string {
+	return f.Lock(contentKey(sum))
+}
+
+func (f *FileCache) RLockContent(sum string) string {
+	return f.RLock(contentKey(sum))
+}
+
+func contentKey(sum string) string {
+	return "sha256-" + sum
+}
+
+func (f *FileCache) path(key string) string {
+	return filepath.Join(f.CacheDir, sanitize(key))
+}
+
+func (f *FileCache) metadataPath(key string) string {
+	return f.path(key) + ".json"
+}
+
+func (f *FileCache) Metadata(key string) (*Metadata, error) {
+	f.metadataMu.Lock()
+	defer f.metadataMu.Unlock()
+
+	return f.metadataLocked(key)
+}
+
+// metadataLocked is Metadata's implementation, assuming metadataMu is
+// already held.
+func (f *FileCache) metadataLocked(key string) (*Metadata, error) {
+	data, err := os.ReadFile(f.metadataPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing cache metadata for %q: %s", key, err)
+	}
+
+	return &m, nil
+}
+
+func (f *FileCache) PutMetadata(key string, m *Metadata) error {
+	f.metadataMu.Lock()
+	defer f.metadataMu.Unlock()
+
+	m.LastAccess = time.Now().UTC()
+	return f.writeMetadataLocked(key, m)
+}
+
+// writeMetadataLocked is PutMetadata's implementation, assuming
+// metadataMu is already held.
+func (f *FileCache) writeMetadataLocked(key string, m *Metadata) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.metadataPath(key), data, 0644)
+}
+
+func (f *FileCache) Keys() ([]string, error) {
+	entries, err := os.ReadDir(f.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) == ".json" {
+			continue
+		}
+		keys = append(keys, e.Name())
+	}
+
+	return keys, nil
+}
+
+func (f *FileCache) Remove(key string) error {
+	if err := os.Remove(f.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Remove(f.metadataPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// sanitize turns an arbitrary cache key into a safe filename,
+// preserving readability for simple keys (the common case) while
+// guaranteeing no path traversal or separator characters reach the
+// filesystem.
+func sanitize(key string) string {
+	safe := true
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			safe = false
+		}
+	}
+
+	if safe && key != "" && key != "." && key != ".." {
+		return key
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testFileCache(t *testing.T) (*FileCache, func()) {
+	dir, err := ioutil.TempDir("", "packer-cache-test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return c, func() { os.RemoveAll(dir) }
+}
+
+func TestFileCache_implementsCache(t *testing.T) {
+	var raw interface{} = &FileCache{}
+	if _, ok := raw.(Cache); !ok {
+		t.Fatal("FileCache should implement Cache")
+	}
+}
+
+func TestFileCache_lockUnlock(t *testing.T) {
+	c, cleanup := testFileCache(t)
+	defer cleanup()
+
+	path := c.Lock("foo.iso")
+	if path == "" {
+		t.Fatal("expected a non-empty path")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c.Unlock("foo.iso")
+
+	readPath := c.RLock("foo.iso")
+	if readPath != path {
+		t.Fatalf("expected same path for the same key, got %q vs %q", readPath, path)
+	}
+	c.Unlock("foo.iso")
+}
+
+func TestFileCache_metadata(t *testing.T) {
+	c, cleanup := testFileCache(t)
+	defer cleanup()
+
+	if m, err := c.Metadata("foo.iso"); err != nil || m != nil {
+		t.Fatalf("expected no metadata yet, got %+v, err %s", m, err)
+	}
+
+	err := c.PutMetadata("foo.iso", &Metadata{
+		Checksum:  "deadbeef",
+		Size:      1024,
+		SourceURL: "http://example.com/foo.iso",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	m, err := c.Metadata("foo.iso")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m.Checksum != "deadbeef" {
+		t.Fatalf("expected checksum deadbeef, got %s", m.Checksum)
+	}
+	if m.LastAccess.IsZero() {
+		t.Fatal("expected LastAccess to be set")
+	}
+}
+
+func TestFileCache_keysAndRemove(t *testing.T) {
+	c, cleanup := testFileCache(t)
+	defer cleanup()
+
+	path := c.Lock("foo.iso")
+	ioutil.WriteFile(path, []byte("hello"), 0644)
+	c.Unlock("foo.iso")
+	c.PutMetadata("foo.iso", &Metadata{Checksum: "deadbeef"})
+
+	keys, err := c.Keys()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(keys) != 1 || keys[0] != "foo.iso" {
+		t.Fatalf("expected [foo.iso], got %v", keys)
+	}
+
+	if err := c.Remove("foo.iso"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	keys, err = c.Keys()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected empty cache after remove, got %v", keys)
+	}
+}
+
+func TestFileCache_rlockTouchesLastAccess(t *testing.T) {
+	c, cleanup := testFileCache(t)
+	defer cleanup()
+
+	path := c.Lock("foo.iso")
+	ioutil.WriteFile(path, []byte("hello"), 0644)
+	c.Unlock("foo.iso")
+
+	if err := c.PutMetadata("foo.iso", &Metadata{Checksum: "deadbeef"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	first, err := c.Metadata("foo.iso")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+
+	second, err := c.Metadata("foo.iso")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !second.LastAccess.After(first.LastAccess) {
+		t.Fatalf("expected RLock to bump LastAccess, got %s then %s", first.LastAccess, second.LastAccess)
+	}
+}
+
+func TestFileCache_concurrentReaders(t *testing.T) {
+	c, cleanup := testFileCache(t)
+	defer cleanup()
+
+	path := c.Lock("foo.iso")
+	ioutil.WriteFile(path, []byte("hello"), 0644)
+	c.Unlock("foo.iso")
+
+	c.RLock("foo.iso")
+	c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+	c.Unlock("foo.iso")
+
+	// A write lock should be obtainable again once both readers have
+	// released, proving neither Unlock call above crashed or leaked
+	// the read lock.
+	done := make(chan struct{})
+	go func() {
+		c.Lock("foo.iso")
+		c.Unlock("foo.iso")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out acquiring write lock; a reader's unlock likely leaked the lock")
+	}
+}
+
+func TestFileCache_sanitizeTraversal(t *testing.T) {
+	c, cleanup := testFileCache(t)
+	defer cleanup()
+
+	path := c.Lock("../../etc/passwd")
+	if path == "" {
+		t.Fatal("expected a non-empty path")
+	}
+	if filepath.Dir(path) != c.CacheDir {
+		t.Fatalf("expected sanitized key to stay inside cache dir, got %q", path)
+	}
+}
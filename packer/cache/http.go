@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// HTTPCache implements Cache as a read-through proxy in front of a
+// single HTTP mirror, for air-gapped environments where builders
+// can't reach the public internet but can reach an internal mirror
+// that serves the same paths. Keys are treated as paths relative to
+// BaseURL. Entries are fetched once and then served from local disk.
+type HTTPCache struct {
+	BaseURL string
+	Client  *http.Client
+
+	local *FileCache
+}
+
+// NewHTTPCache creates an HTTPCache that fetches missing entries from
+// baseURL and stages them under localDir.
+func NewHTTPCache(baseURL, localDir string) (*HTTPCache, error) {
+	local, err := NewFileCache(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPCache{BaseURL: baseURL, Client: http.DefaultClient, local: local}, nil
+}
+
+func (h *HTTPCache) Lock(key string) string {
+	return h.local.Lock(key)
+}
+
+func (h *HTTPCache) RLock(key string) string {
+	path := h.local.RLock(key)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		h.local.Unlock(key)
+		h.local.Lock(key)
+		if err := h.fetch(key, path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to fetch %q through cache mirror %s: %s\n",
+				key, h.BaseURL, err)
+		}
+		h.local.Unlock(key)
+		h.local.RLock(key)
+	}
+
+	return path
+}
+
+func (h *HTTPCache) fetch(key, destPath string) error {
+	resp, err := h.Client.Get(h.BaseURL + "/" + key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mirror returned status %d for %q", resp.StatusCode, key)
+	}
+
+	f, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	checksum := NewChecksumReader(resp.Body)
+	n, err := io.Copy(f, checksum)
+	if err != nil {
+		return err
+	}
+
+	return h.local.PutMetadata(key, &Metadata{
+		Checksum:  checksum.Sum256(),
+		Size:      n,
+		SourceURL: h.BaseURL + "/" + key,
+	})
+}
+
+func (h *HTTPCache) Unlock(key string) { h.local.Unlock(key) }
+
+func (h *HTTPCache) LockContent(sum string) string  { return h.Lock(contentKey(sum)) }
+func (h *HTTPCache) RLockContent(sum string) string { return h.RLock(contentKey(sum)) }
+
+func (h *HTTPCache) Metadata(key string) (*Metadata, error)    { return h.local.Metadata(key) }
+func (h *HTTPCache) PutMetadata(key string, m *Metadata) error { return h.local.PutMetadata(key, m) }
+func (h *HTTPCache) Keys() ([]string, error)                   { return h.local.Keys() }
+func (h *HTTPCache) Remove(key string) error                   { return h.local.Remove(key) }
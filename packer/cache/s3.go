@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// S3Bucket is the minimal interface S3Cache needs from an S3 client,
+// so tests can substitute a fake without pulling in the AWS SDK.
+type S3Bucket interface {
+	// Download fetches bucket/key to localPath, returning an error if
+	// the object can't be fetched, including when it doesn't exist.
+	Download(bucket, key, localPath string) error
+
+	// Upload puts the contents of localPath to bucket/key.
+	Upload(bucket, key, localPath string) error
+}
+
+// S3Cache implements Cache on top of a remote S3 bucket, using a
+// local FileCache as scratch space: RLock stages the object locally
+// (downloading it first if it isn't already present), and Unlock of a
+// Lock pushes anything written back up to S3 so that subsequent
+// builds, including on other CI runners, can reuse it.
+type S3Cache struct {
+	Bucket S3Bucket
+	Name   string
+	Prefix string
+
+	local *FileCache
+
+	// writersMu guards writers, which records whether the currently
+	// held lock for a key is the write side, so Unlock only uploads
+	// for entries that were actually (potentially) written to, not
+	// every time a reader releases its lock.
+	writersMu sync.Mutex
+	writers   map[string]bool
+}
+
+// NewS3Cache creates an S3Cache backed by bucket, storing objects
+// under prefix (joined with "/"), and using localDir as local
+// staging space for in-flight downloads and uploads.
+func NewS3Cache(bucket S3Bucket, name, prefix, localDir string) (*S3Cache, error) {
+	local, err := NewFileCache(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Cache{Bucket: bucket, Name: name, Prefix: prefix, local: local}, nil
+}
+
+func (s *S3Cache) objectKey(key string) string {
+	if s.Prefix == "" {
+		return key
+	}
+	return s.Prefix + "/" + key
+}
+
+func (s *S3Cache) setWriter(key string, write bool) {
+	s.writersMu.Lock()
+	defer s.writersMu.Unlock()
+
+	if s.writers == nil {
+		s.writers = make(map[string]bool)
+	}
+	s.writers[key] = write
+}
+
+func (s *S3Cache) isWriter(key string) bool {
+	s.writersMu.Lock()
+	defer s.writersMu.Unlock()
+
+	return s.writers[key]
+}
+
+func (s *S3Cache) Lock(key string) string {
+	path := s.local.Lock(key)
+	s.setWriter(key, true)
+	return path
+}
+
+func (s *S3Cache) RLock(key string) string {
+	path := s.local.RLock(key)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		s.local.Unlock(key)
+		s.local.Lock(key)
+		if err := s.Bucket.Download(s.Name, s.objectKey(key), path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to fetch %q from s3://%s/%s: %s\n",
+				key, s.Name, s.objectKey(key), err)
+			os.Remove(path)
+		}
+		s.local.Unlock(key)
+		s.local.RLock(key)
+	}
+
+	s.setWriter(key, false)
+	return path
+}
+
+func (s *S3Cache) Unlock(key string) {
+	if s.isWriter(key) {
+		path := s.local.path(key)
+		if _, err := os.Stat(path); err == nil {
+			if err := s.Bucket.Upload(s.Name, s.objectKey(key), path); err != nil {
+				// The local copy is still valid; a failed remote sync just
+				// means the entry isn't shared through S3 until it is
+				// written again under Lock, which uploads it anew.
+				fmt.Fprintf(os.Stderr, "Warning: failed to sync cache entry %q to s3://%s/%s: %s\n",
+					key, s.Name, s.objectKey(key), err)
+			}
+		}
+	}
+
+	s.local.Unlock(key)
+}
+
+func (s *S3Cache) LockContent(sum string) string  { return s.Lock(contentKey(sum)) }
+func (s *S3Cache) RLockContent(sum string) string { return s.RLock(contentKey(sum)) }
+
+func (s *S3Cache) Metadata(key string) (*Metadata, error)    { return s.local.Metadata(key) }
+func (s *S3Cache) PutMetadata(key string, m *Metadata) error { return s.local.PutMetadata(key, m) }
+func (s *S3Cache) Keys() ([]string, error)                   { return s.local.Keys() }
+func (s *S3Cache) Remove(key string) error                   { return s.local.Remove(key) }
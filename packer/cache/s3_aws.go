@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// awsS3Bucket is the default S3Bucket implementation, backed by the
+// official AWS SDK and the standard credential chain (environment,
+// shared config, or instance role), matching how builders elsewhere
+// in packer authenticate to AWS.
+type awsS3Bucket struct {
+	downloader *s3manager.Downloader
+	uploader   *s3manager.Uploader
+}
+
+func newDefaultS3Bucket() *awsS3Bucket {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	return &awsS3Bucket{
+		downloader: s3manager.NewDownloader(sess),
+		uploader:   s3manager.NewUploader(sess),
+	}
+}
+
+func (b *awsS3Bucket) Download(bucket, key, localPath string) error {
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = b.downloader.Download(f, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *awsS3Bucket) Upload(bucket, key, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = b.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	})
+	return err
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type fakeS3Bucket struct {
+	downloads   int
+	uploads     int
+	downloadErr error
+}
+
+func (f *fakeS3Bucket) Download(bucket, key, localPath string) error {
+	f.downloads++
+	if f.downloadErr != nil {
+		return f.downloadErr
+	}
+	return ioutil.WriteFile(localPath, []byte("remote contents"), 0644)
+}
+
+func (f *fakeS3Bucket) Upload(bucket, key, localPath string) error {
+	f.uploads++
+	return nil
+}
+
+func testS3Cache(t *testing.T) (*S3Cache, *fakeS3Bucket, func()) {
+	dir, err := ioutil.TempDir("", "packer-s3cache-test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	bucket := &fakeS3Bucket{}
+	c, err := NewS3Cache(bucket, "my-bucket", "packer", dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return c, bucket, func() { os.RemoveAll(dir) }
+}
+
+func TestS3Cache_readDoesNotReupload(t *testing.T) {
+	c, bucket, cleanup := testS3Cache(t)
+	defer cleanup()
+
+	path := c.Lock("foo.iso")
+	ioutil.WriteFile(path, []byte("hello"), 0644)
+	c.Unlock("foo.iso")
+
+	if bucket.uploads != 1 {
+		t.Fatalf("expected exactly one upload after the write, got %d", bucket.uploads)
+	}
+
+	c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+	c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+
+	if bucket.uploads != 1 {
+		t.Fatalf("expected reads not to trigger re-upload, got %d uploads", bucket.uploads)
+	}
+}
+
+func TestS3Cache_rlockFetchesOnMiss(t *testing.T) {
+	c, bucket, cleanup := testS3Cache(t)
+	defer cleanup()
+
+	path := c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+
+	if bucket.downloads != 1 {
+		t.Fatalf("expected exactly one download, got %d", bucket.downloads)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "remote contents" {
+		t.Fatalf("expected downloaded contents, got %q", data)
+	}
+
+	// A second RLock should be served from the local copy, not refetch.
+	c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+	if bucket.downloads != 1 {
+		t.Fatalf("expected cached entry not to be re-downloaded, got %d downloads", bucket.downloads)
+	}
+}
+
+func TestS3Cache_rlockFailedDownloadLeavesNoFile(t *testing.T) {
+	c, bucket, cleanup := testS3Cache(t)
+	defer cleanup()
+
+	bucket.downloadErr = errors.New("access denied")
+
+	path := c.RLock("foo.iso")
+	c.Unlock("foo.iso")
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no local file after a failed download, got err=%v", err)
+	}
+}
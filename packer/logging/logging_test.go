@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]Level{
+		"":        DebugLevel,
+		"1":       DebugLevel,
+		"trace":   TraceLevel,
+		"TRACE":   TraceLevel,
+		"debug":   DebugLevel,
+		"info":    InfoLevel,
+		"WARN":    WarnLevel,
+		"warning": WarnLevel,
+		"error":   ErrorLevel,
+		"bogus":   DebugLevel,
+	}
+
+	for raw, expected := range cases {
+		if actual := ParseLevel(raw); actual != expected {
+			t.Errorf("ParseLevel(%q) = %v, expected %v", raw, actual, expected)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if ParseFormat("json") != JSONFormat {
+		t.Fatal("expected json to parse as JSONFormat")
+	}
+	if ParseFormat("JSON") != JSONFormat {
+		t.Fatal("expected JSON to parse as JSONFormat")
+	}
+	if ParseFormat("") != TextFormat {
+		t.Fatal("expected empty string to parse as TextFormat")
+	}
+	if ParseFormat("text") != TextFormat {
+		t.Fatal("expected text to parse as TextFormat")
+	}
+}
+
+func TestLogger_levelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WarnLevel, TextFormat, "core")
+
+	l.Debug("should not appear")
+	l.Warn("should appear")
+	l.Flush()
+
+	out := buf.String()
+	if strings.Contains(out, "should not appear") {
+		t.Fatalf("debug line should have been filtered out: %s", out)
+	}
+	if !strings.Contains(out, "should appear") {
+		t.Fatalf("warn line missing from output: %s", out)
+	}
+}
+
+func TestLogger_json(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, InfoLevel, JSONFormat, "builder.amazon-chroot")
+
+	l.Info("hello %s", "world")
+	l.Flush()
+
+	var e entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("expected valid JSON line, got error: %s\noutput: %s", err, buf.String())
+	}
+
+	if e.Level != "INFO" {
+		t.Errorf("expected level INFO, got %s", e.Level)
+	}
+	if e.Component != "builder.amazon-chroot" {
+		t.Errorf("expected component builder.amazon-chroot, got %s", e.Component)
+	}
+	if e.Message != "hello world" {
+		t.Errorf("expected message 'hello world', got %q", e.Message)
+	}
+	if e.Timestamp == "" {
+		t.Error("expected non-empty timestamp")
+	}
+}
+
+func TestLogger_stdlibAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, InfoLevel, JSONFormat, "core")
+
+	stdlog := log.New(l, "", 0)
+	stdlog.Printf("hello from stdlib log")
+	l.Flush()
+
+	var e entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("expected valid JSON line from the stdlib adapter, got error: %s\noutput: %s", err, buf.String())
+	}
+	if e.Message != "hello from stdlib log" {
+		t.Errorf("expected message 'hello from stdlib log', got %q", e.Message)
+	}
+}
+
+func TestLogger_withComponent(t *testing.T) {
+	var buf bytes.Buffer
+	root := New(&buf, TraceLevel, JSONFormat, "core")
+	plugin := root.WithComponent("plugin.amazon-chroot")
+
+	plugin.Trace("from plugin")
+	root.Flush()
+
+	var e entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("expected valid JSON line, got error: %s", err)
+	}
+	if e.Component != "plugin.amazon-chroot" {
+		t.Errorf("expected component plugin.amazon-chroot, got %s", e.Component)
+	}
+}
@@ -0,0 +1,39 @@
+// Package plugin manages the lifecycle of the plugin subprocesses
+// packer's component loaders launch (builders, provisioners,
+// post-processors, hooks, and commands each run as a separate
+// executable, talked to over RPC). This file covers only the
+// process-wide cleanup entry point packer.go depends on; the RPC
+// client/server implementation lives alongside it.
+package plugin
+
+import "sync"
+
+var (
+	cleanupMu    sync.Mutex
+	cleanupHooks []func()
+)
+
+// RegisterCleanupHook registers f to run every time CleanupClients
+// runs. packer.go uses this to flush its buffered logging.Logger so
+// that log lines still sitting in the buffer are written out before
+// packer exits.
+func RegisterCleanupHook(f func()) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	cleanupHooks = append(cleanupHooks, f)
+}
+
+// CleanupClients runs every hook registered with RegisterCleanupHook,
+// in registration order. It does not itself track or kill plugin
+// subprocess clients. It is safe to call more than once, which
+// packer.go relies on: it calls this explicitly on every exit path.
+func CleanupClients() {
+	cleanupMu.Lock()
+	hooks := make([]func(), len(cleanupHooks))
+	copy(hooks, cleanupHooks)
+	cleanupMu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
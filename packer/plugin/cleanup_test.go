@@ -0,0 +1,23 @@
+package plugin
+
+import "testing"
+
+func TestCleanupClients_runsRegisteredHooks(t *testing.T) {
+	cleanupMu.Lock()
+	cleanupHooks = nil
+	cleanupMu.Unlock()
+
+	calls := 0
+	RegisterCleanupHook(func() { calls++ })
+	RegisterCleanupHook(func() { calls++ })
+
+	CleanupClients()
+	if calls != 2 {
+		t.Fatalf("expected both hooks to run once, got %d calls", calls)
+	}
+
+	CleanupClients()
+	if calls != 4 {
+		t.Fatalf("expected CleanupClients to be safely callable more than once, got %d calls", calls)
+	}
+}